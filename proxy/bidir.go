@@ -0,0 +1,107 @@
+package proxy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/xtls/xray-core/common/buf"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/features/stats"
+	"github.com/xtls/xray-core/transport/internet/stat"
+)
+
+// bidirCopyFunc drives both directions of a TCP<->TCP relay with a single platform-native
+// call instead of two independent CopyRawConnIfExist/buf.Copy goroutines. It returns
+// handled=false when the platform has no such backend, or the given pair can't be served
+// by it, in which case CopyRawConnBidir falls back to the generic two-goroutine buf.Copy
+// relay.
+type bidirCopyFunc func(ctx context.Context, connA, connB *net.TCPConn, timer signal.ActivityUpdater, readA, writeA, userUp, readB, writeB, userDown stats.Counter) (handled bool, err error)
+
+// bidirCopy is assigned by the platform-specific file built for the current GOOS (see
+// bidir_linux.go). It stays nil where no such backend exists, and CopyRawConnBidir always
+// falls back to copyBidirFallback.
+var bidirCopy bidirCopyFunc
+
+// CopyRawConnBidir relays connA<->connB in both directions from a single call, letting a
+// platform backend (see bidirCopy) coordinate shutdown between the two directions instead
+// of running them as two unrelated goroutines. countersA and countersB are the optional
+// per-user counters for whichever session connA and connB belong to: the uplink side of
+// countersA is credited for the connA->connB direction, the downlink side of countersB for
+// connB->connA.
+//
+// When no platform backend is available, either side isn't a plain *net.TCPConn (e.g.
+// TLS-wrapped), or disableRawCopy is set (e.g. from the outbound handler's own config), this
+// falls back to two buf.Copy goroutines. Only the stat.CounterConnection wrapper is stripped;
+// a TLS/REALITY conn stays wrapped and is relayed through its TLS layer.
+//
+// Dokodemo, Freedom, VLESS and Trojan are the handlers meant to switch to this API wherever
+// both endpoints are plain TCP (replacing their two-goroutine CopyRawConnIfExist calls);
+// none of those packages are part of this checkout, so that wiring isn't included here and
+// this has no caller yet.
+func CopyRawConnBidir(ctx context.Context, connA, connB net.Conn, timer signal.ActivityUpdater, countersA, countersB *UserCounters, disableRawCopy bool) error {
+	var readA, writeA, readB, writeB stats.Counter
+	if statConn, ok := connA.(*stat.CounterConnection); ok {
+		connA, readA, writeA = statConn.Connection, statConn.ReadCounter, statConn.WriteCounter
+	}
+	if statConn, ok := connB.(*stat.CounterConnection); ok {
+		connB, readB, writeB = statConn.Connection, statConn.ReadCounter, statConn.WriteCounter
+	}
+
+	var userUp, userDown stats.Counter
+	if countersA != nil {
+		userUp = countersA.Uplink
+	}
+	if countersB != nil {
+		userDown = countersB.Downlink
+	}
+
+	if !disableRawCopy && bidirCopy != nil {
+		if tcA, ok := connA.(*net.TCPConn); ok {
+			if tcB, ok := connB.(*net.TCPConn); ok {
+				handled, err := bidirCopy(ctx, tcA, tcB, timer, readA, writeA, userUp, readB, writeB, userDown)
+				if handled {
+					return err
+				}
+			}
+		}
+	}
+
+	return copyBidirFallback(connA, connB, timer, readA, writeA, userUp, readB, writeB, userDown)
+}
+
+// copyBidirFallback runs the two directions as independent buf.Copy goroutines. A
+// direction that finishes (EOF or error) half-closes its destination via CloseWrite so the
+// peer goroutine unblocks and finishes on its own EOF rather than hanging forever.
+func copyBidirFallback(connA, connB net.Conn, timer signal.ActivityUpdater, readA, writeA, userUp, readB, writeB, userDown stats.Counter) error {
+	var wg sync.WaitGroup
+	var errAB, errBA error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		errAB = buf.Copy(buf.NewReader(connA), buf.NewWriter(connB), buf.UpdateActivity(timer), buf.AddToStatCounter(readA), buf.AddToStatCounter(writeB), buf.AddToStatCounter(userUp))
+		closeWrite(connB)
+	}()
+	go func() {
+		defer wg.Done()
+		errBA = buf.Copy(buf.NewReader(connB), buf.NewWriter(connA), buf.UpdateActivity(timer), buf.AddToStatCounter(readB), buf.AddToStatCounter(writeA), buf.AddToStatCounter(userDown))
+		closeWrite(connA)
+	}()
+	wg.Wait()
+
+	if errAB != nil {
+		return newError("failed to process request").Base(errAB)
+	}
+	if errBA != nil {
+		return newError("failed to process response").Base(errBA)
+	}
+	return nil
+}
+
+// closeWrite half-closes conn for writing if it supports it, so the peer relaying the other
+// direction observes EOF instead of blocking until the whole session's idle timer fires.
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+	}
+}
@@ -0,0 +1,140 @@
+//go:build linux || android
+
+package proxy
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/features/stats"
+)
+
+func init() {
+	bidirCopy = spliceBidir
+}
+
+// spliceBidir relays connA<->connB with one splice(2) loop per direction, each driven from
+// its own goroutine and its own private non-blocking pipe, so neither direction waits on
+// the other to make progress. Half-close is honored: once a direction's source hits EOF it
+// CloseWrite()s the destination and returns, letting the other direction keep running until
+// it also EOFs (or the session's idle timer elsewhere tears the whole thing down).
+func spliceBidir(ctx context.Context, connA, connB *net.TCPConn, timer signal.ActivityUpdater, readA, writeA, userUp, readB, writeB, userDown stats.Counter) (bool, error) {
+	newError("CopyRawConnBidir splice").WriteToLog(session.ExportIDToError(ctx))
+
+	var wg sync.WaitGroup
+	var errAB, errBA error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		errAB = spliceDirection(connA, connB, timer, readA, writeB, userUp)
+	}()
+	go func() {
+		defer wg.Done()
+		errBA = spliceDirection(connB, connA, timer, readB, writeA, userDown)
+	}()
+	wg.Wait()
+
+	if errAB != nil {
+		return true, errAB
+	}
+	return true, errBA
+}
+
+// spliceDirection drains srcConn into dstConn through a private relay pipe. It runs splice
+// directly on srcConn/dstConn's own fds via SyscallConn rather than dup'ing them with File(),
+// gating each stage on the runtime poller (Read-readiness of srcConn, Write-readiness of
+// dstConn) instead of a manual epoll set. Because the syscalls run on the original fds,
+// closing either *net.TCPConn — including when the session's idle timer fires — interrupts
+// an in-flight splice the same way it would interrupt a regular Read/Write. On EOF from
+// srcConn (a zero-length, no-error splice) it half-closes dstConn via CloseWrite.
+func spliceDirection(srcConn, dstConn *net.TCPConn, timer signal.ActivityUpdater, readCounter, writeCounter, userCounter stats.Counter) error {
+	rawSrc, err := srcConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rawDst, err := dstConn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	pipeFds := make([]int, 2)
+	if err := unix.Pipe2(pipeFds, unix.O_NONBLOCK|unix.O_CLOEXEC); err != nil {
+		return err
+	}
+	defer unix.Close(pipeFds[0])
+	defer unix.Close(pipeFds[1])
+	pipeR, pipeW := pipeFds[0], pipeFds[1]
+
+	var total int64
+	for {
+		var n int64
+		var stageErr error
+		readErr := rawSrc.Read(func(fd uintptr) (done bool) {
+			for {
+				nn, err := unix.Splice(int(fd), nil, pipeW, nil, 1<<20, unix.SPLICE_F_NONBLOCK|unix.SPLICE_F_MOVE)
+				if err == unix.EINTR {
+					continue
+				}
+				if err == unix.EAGAIN {
+					return false
+				}
+				n = nn
+				stageErr = err
+				return true
+			}
+		})
+		if readErr != nil {
+			addToCounters(total, readCounter, writeCounter, userCounter)
+			return readErr
+		}
+		if stageErr != nil {
+			addToCounters(total, readCounter, writeCounter, userCounter)
+			return stageErr
+		}
+		if n == 0 {
+			break
+		}
+
+		remaining := n
+		for remaining > 0 {
+			var stageErr error
+			writeErr := rawDst.Write(func(fd uintptr) (done bool) {
+				for remaining > 0 {
+					wn, err := unix.Splice(pipeR, nil, int(fd), nil, int(remaining), unix.SPLICE_F_NONBLOCK|unix.SPLICE_F_MOVE)
+					if wn > 0 {
+						remaining -= wn
+						total += wn
+						timer.Update()
+						continue
+					}
+					if err == unix.EINTR {
+						continue
+					}
+					if err == unix.EAGAIN {
+						return false
+					}
+					stageErr = err
+					return true
+				}
+				return true
+			})
+			if writeErr != nil {
+				addToCounters(total, readCounter, writeCounter, userCounter)
+				return writeErr
+			}
+			if stageErr != nil {
+				addToCounters(total, readCounter, writeCounter, userCounter)
+				return stageErr
+			}
+		}
+	}
+
+	addToCounters(total, readCounter, writeCounter, userCounter)
+	dstConn.CloseWrite()
+	return nil
+}
@@ -9,7 +9,6 @@ import (
 	"context"
 	gotls "crypto/tls"
 	"io"
-	"runtime"
 
 	"github.com/xtls/xray-core/common/buf"
 	"github.com/xtls/xray-core/common/errors"
@@ -50,6 +49,28 @@ type UserManager interface {
 	RemoveUser(context.Context, string) error
 }
 
+// UserCounters holds the per-user traffic counters that CopyRawConnIfExist accumulates
+// alongside the existing per-inbound/per-outbound tag counters. Inbound and outbound
+// proxies populate this on session.Content once a connection has been authenticated to
+// a specific user; see UserCounterManager for the concrete helper that allocates and
+// tracks them.
+type UserCounters struct {
+	Uplink   stats.Counter
+	Downlink stats.Counter
+}
+
+// UserQuerier is the interface for Inbounds and Outbounds that can enumerate their users
+// and report live traffic for them, mirroring the `outbound>>>tag>>>traffic>>>uplink`
+// naming pattern as `user>>>email>>>traffic>>>{up,down}link`. UserCounterManager
+// (usercounters.go) is the concrete, reusable implementation.
+type UserQuerier interface {
+	// ListUsers returns the emails of all users currently known to the proxy.
+	ListUsers() []string
+
+	// GetUserStats returns the traffic counters registered for the given user email.
+	GetUserStats(email string) (*UserCounters, bool)
+}
+
 type GetInbound interface {
 	GetInbound() Inbound
 }
@@ -58,55 +79,54 @@ type GetOutbound interface {
 	GetOutbound() Outbound
 }
 
+// unwrapConn strips the stat.CounterConnection and TLS/REALITY wrappers a proxy layers on
+// top of a raw net.Conn, returning the underlying connection together with whatever
+// read/write stat counters were attached to it (either may be nil).
+func unwrapConn(conn net.Conn) (net.Conn, stats.Counter, stats.Counter) {
+	var readCounter, writeCounter stats.Counter
+	if statConn, ok := conn.(*stat.CounterConnection); ok {
+		conn = statConn.Connection
+		readCounter = statConn.ReadCounter
+		writeCounter = statConn.WriteCounter
+	}
+	if xc, ok := conn.(*gotls.Conn); ok {
+		conn = xc.NetConn()
+	} else if utlsConn, ok := conn.(*tls.UConn); ok {
+		conn = utlsConn.NetConn()
+	} else if realityConn, ok := conn.(*reality.Conn); ok {
+		conn = realityConn.NetConn()
+	}
+	return conn, readCounter, writeCounter
+}
+
 // CopyRawConnIfExist use the most efficient copy method.
-// - If caller don't want to turn on splice, do not pass in both reader conn and writer conn
-// - reader and writer are from *transport.Link, one of them must be nil (idicate the direction of copy)
-func CopyRawConnIfExist(ctx context.Context, readerConn net.Conn, writerConn net.Conn, reader buf.Reader, writer buf.Writer, timer signal.ActivityUpdater) error {
-	var readCounter stats.Counter
+//   - If caller don't want to turn on splice, do not pass in both reader conn and writer conn
+//   - reader and writer are from *transport.Link, one of them must be nil (idicate the direction of copy)
+//   - userCounter, if not nil, is the per-user traffic counter (one side of a UserCounters) for this direction
+//   - the zero-copy fast path (splice on Linux/Android, see rawCopy) is picked per GOOS;
+//     callers pass disableRawCopy (e.g. from the outbound handler's own config) to force the
+//     regular buf.Copy loop instead
+func CopyRawConnIfExist(ctx context.Context, readerConn net.Conn, writerConn net.Conn, reader buf.Reader, writer buf.Writer, timer signal.ActivityUpdater, userCounter stats.Counter, disableRawCopy bool) error {
+	var readCounter, writeCounter stats.Counter
 	if readerConn != nil {
-		statConn, ok := readerConn.(*stat.CounterConnection)
-		if ok {
-			readerConn = statConn.Connection
-			readCounter = statConn.ReadCounter
-		}
-		if xc, ok := readerConn.(*gotls.Conn); ok {
-			readerConn = xc.NetConn()
-		} else if utlsConn, ok := readerConn.(*tls.UConn); ok {
-			readerConn = utlsConn.NetConn()
-		} else if realityConn, ok := readerConn.(*reality.Conn); ok {
-			readerConn = realityConn.NetConn()
-		}
+		readerConn, readCounter, _ = unwrapConn(readerConn)
 	}
-	var writeCounter stats.Counter
 	if writerConn != nil {
-		statConn, ok := writerConn.(*stat.CounterConnection)
-		if ok {
-			writerConn = statConn.Connection
-			writeCounter = statConn.WriteCounter
-		}
-		if xc, ok := writerConn.(*gotls.Conn); ok {
-			writerConn = xc.NetConn()
-		} else if utlsConn, ok := writerConn.(*tls.UConn); ok {
-			writerConn = utlsConn.NetConn()
-		} else if realityConn, ok := writerConn.(*reality.Conn); ok {
-			writerConn = realityConn.NetConn()
-		}
+		writerConn, _, writeCounter = unwrapConn(writerConn)
 	}
 
-	if tc, ok := writerConn.(*net.TCPConn); ok && readerConn != nil && writerConn != nil && (runtime.GOOS == "linux" || runtime.GOOS == "android") {
-		newError("CopyRawConn splice").WriteToLog(session.ExportIDToError(ctx))
-		runtime.Gosched() // necessary
-		w, err := tc.ReadFrom(readerConn)
-		if readCounter != nil {
-			readCounter.Add(w)
-		}
-		if writeCounter != nil {
-			writeCounter.Add(w)
-		}
-		if err != nil && errors.Cause(err) != io.EOF {
-			return err
+	if !disableRawCopy && rawCopy != nil && readerConn != nil && writerConn != nil {
+		if rtc, ok := readerConn.(*net.TCPConn); ok {
+			if wtc, ok := writerConn.(*net.TCPConn); ok {
+				handled, err := rawCopy(ctx, rtc, wtc, timer, readCounter, writeCounter, userCounter)
+				if handled {
+					if err != nil && errors.Cause(err) != io.EOF {
+						return err
+					}
+					return nil
+				}
+			}
 		}
-		return nil
 	}
 	if reader == nil {
 		newError("CopyRawConn copy from readerConn to *transport.Link.Writer").WriteToLog(session.ExportIDToError(ctx))
@@ -118,7 +138,7 @@ func CopyRawConnIfExist(ctx context.Context, readerConn net.Conn, writerConn net
 		writer = buf.NewWriter(writerConn)
 		readCounter = nil
 	}
-	if err := buf.Copy(reader, writer, buf.UpdateActivity(timer), buf.AddToStatCounter(readCounter), buf.AddToStatCounter(writeCounter)); err != nil {
+	if err := buf.Copy(reader, writer, buf.UpdateActivity(timer), buf.AddToStatCounter(readCounter), buf.AddToStatCounter(writeCounter), buf.AddToStatCounter(userCounter)); err != nil {
 		return newError("failed to process response").Base(err)
 	}
 	return nil
@@ -0,0 +1,41 @@
+package proxy
+
+import (
+	"context"
+
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/features/stats"
+)
+
+// rawCopyFunc performs a zero-copy transfer from readerConn to writerConn, both already
+// unwrapped down to their underlying *net.TCPConn. It returns handled=false when this
+// platform's backend cannot service the given pair (e.g. an unsupported fd type or a kernel
+// too old for the feature), in which case CopyRawConnIfExist falls back to buf.Copy.
+type rawCopyFunc func(ctx context.Context, readerConn, writerConn *net.TCPConn, timer signal.ActivityUpdater, readCounter, writeCounter, userCounter stats.Counter) (handled bool, err error)
+
+// rawCopy is assigned by the platform-specific file built for the current GOOS: see
+// rawcopy_linux.go/rawcopy_splice_linux.go (Linux/Android). It stays nil on platforms with
+// no zero-copy backend — currently FreeBSD, Darwin and Windows, see rawcopy_bsd.go and
+// rawcopy_windows.go — in which case CopyRawConnIfExist always goes through buf.Copy.
+//
+// STATUS, re: the original ask for this registry (FreeBSD sendfile, Windows TransmitFile,
+// Linux io_uring): only the pluggable-per-GOOS refactor itself landed. FreeBSD/Darwin
+// sendfile(2) cannot do socket-to-socket transfers and Windows TransmitFile cannot take a
+// peer socket as its source, so those two backends are infeasible as specified, not just
+// unimplemented; a real io_uring backend was attempted and pulled for a deadlock (see
+// rawcopy_linux.go). Net effect for three of three requested platforms is "no new zero-copy
+// backend". This needs to go back to the ticket owner to renegotiate scope (e.g. a
+// sendfile-from-file path for static content, or Registered I/O on Windows) rather than be
+// treated as done against the original ask.
+var rawCopy rawCopyFunc
+
+// addToCounters adds n to every non-nil counter. Several independent counters (per-conn,
+// per-tag, per-user) may all need crediting for the same transferred byte count.
+func addToCounters(n int64, counters ...stats.Counter) {
+	for _, c := range counters {
+		if c != nil {
+			c.Add(n)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build freebsd || darwin
+
+package proxy
+
+// sendfile(2) on FreeBSD/Darwin only ever transfers from a regular file (or shared-memory
+// object) into a socket; the source fd must not itself be a connected socket. Go's own
+// stdlib encodes the same restriction (net/sendfile_unix_alt.go's sendFile refuses to even
+// attempt sendfile unless the source is an *os.File), and neither FreeBSD nor Darwin has a
+// splice(2) equivalent for socket-to-socket copies. A prior attempt at this backend called
+// syscall.Sendfile with a TCP socket as the source, which fails the syscall on every real
+// invocation and, worse, was wired to treat any non-EAGAIN/EINTR error as a hard failure
+// rather than falling back to buf.Copy — breaking every TCP relay on these platforms instead
+// of just missing the optimization. Rather than ship a backend that looks functional but
+// always fails, leave rawCopy nil on FreeBSD/Darwin until there's a real zero-copy mechanism
+// to build on; CopyRawConnIfExist already falls back to buf.Copy when rawCopy is nil.
@@ -0,0 +1,12 @@
+//go:build linux || android
+
+package proxy
+
+// An `iouring` build tag was previously reserved for a dedicated Linux io_uring backend.
+// That implementation never actually submitted SQEs/read CQEs and had a deadlock under
+// backpressure, so it was pulled; plain splice (below) is the only Linux/Android backend
+// for now regardless of the `iouring` tag. A real io_uring backend can reclaim the tag
+// later.
+func init() {
+	rawCopy = spliceCopy
+}
@@ -0,0 +1,180 @@
+//go:build linux
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/xtls/xray-core/common/net"
+)
+
+// fakeActivityUpdater satisfies signal.ActivityUpdater without pulling in a real idle timer.
+type fakeActivityUpdater struct{}
+
+func (fakeActivityUpdater) Update() {}
+
+// loopbackPair dials a fresh *net.TCPConn pair over 127.0.0.1: server is the end handed to
+// the code under test (CopyRawConnIfExist/CopyRawConnBidir), client is the end the test
+// drives directly to push bytes through the relay and observe what comes out the other side.
+func loopbackPair(t *testing.T) (server, client *net.TCPConn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	acceptErr := make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			acceptErr <- err
+			return
+		}
+		accepted <- conn
+	}()
+
+	client, err = net.DialTCP("tcp", nil, ln.Addr().(*net.TCPAddr))
+	if err != nil {
+		t.Fatalf("DialTCP: %v", err)
+	}
+
+	select {
+	case conn := <-accepted:
+		server = conn.(*net.TCPConn)
+	case err := <-acceptErr:
+		t.Fatalf("Accept: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for Accept")
+	}
+	return server, client
+}
+
+func TestCopyRawConnIfExistSpliceLoopback(t *testing.T) {
+	readerServer, readerClient := loopbackPair(t)
+	defer readerServer.Close()
+	defer readerClient.Close()
+	writerServer, writerClient := loopbackPair(t)
+	defer writerServer.Close()
+	defer writerClient.Close()
+
+	const payload = "the quick brown fox jumps over the lazy dog"
+	userCounter := &fakeCounter{}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- CopyRawConnIfExist(context.Background(), readerServer, writerServer, nil, nil, fakeActivityUpdater{}, userCounter, false)
+	}()
+
+	if _, err := readerClient.Write([]byte(payload)); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	readerClient.CloseWrite()
+
+	got := make([]byte, len(payload))
+	if _, err := io.ReadFull(writerClient, got); err != nil {
+		t.Fatalf("ReadFull: %v", err)
+	}
+	if string(got) != payload {
+		t.Fatalf("relayed payload = %q, want %q", got, payload)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("CopyRawConnIfExist: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("CopyRawConnIfExist did not return")
+	}
+
+	if userCounter.Value() != int64(len(payload)) {
+		t.Fatalf("userCounter = %d, want %d", userCounter.Value(), len(payload))
+	}
+}
+
+func TestCopyRawConnBidirSpliceLoopbackHalfClose(t *testing.T) {
+	serverA, clientA := loopbackPair(t)
+	defer serverA.Close()
+	defer clientA.Close()
+	serverB, clientB := loopbackPair(t)
+	defer serverB.Close()
+	defer clientB.Close()
+
+	countersA := &UserCounters{Uplink: &fakeCounter{}, Downlink: &fakeCounter{}}
+	countersB := &UserCounters{Uplink: &fakeCounter{}, Downlink: &fakeCounter{}}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- CopyRawConnBidir(context.Background(), serverA, serverB, fakeActivityUpdater{}, countersA, countersB, false)
+	}()
+
+	const request = "request-bytes"
+	if _, err := clientA.Write([]byte(request)); err != nil {
+		t.Fatalf("clientA.Write: %v", err)
+	}
+	gotRequest := make([]byte, len(request))
+	if _, err := io.ReadFull(clientB, gotRequest); err != nil {
+		t.Fatalf("clientB ReadFull: %v", err)
+	}
+	if !bytes.Equal(gotRequest, []byte(request)) {
+		t.Fatalf("A->B payload = %q, want %q", gotRequest, request)
+	}
+
+	const response = "response-bytes"
+	if _, err := clientB.Write([]byte(response)); err != nil {
+		t.Fatalf("clientB.Write: %v", err)
+	}
+	gotResponse := make([]byte, len(response))
+	if _, err := io.ReadFull(clientA, gotResponse); err != nil {
+		t.Fatalf("clientA ReadFull: %v", err)
+	}
+	if !bytes.Equal(gotResponse, []byte(response)) {
+		t.Fatalf("B->A payload = %q, want %q", gotResponse, response)
+	}
+
+	// Half-close A's write side: the relay should observe EOF on the A->B direction,
+	// CloseWrite() serverB, and clientB should see EOF without the B->A direction breaking.
+	clientA.CloseWrite()
+
+	buf := make([]byte, 1)
+	if n, err := clientB.Read(buf); err != io.EOF || n != 0 {
+		t.Fatalf("clientB.Read after A half-close = (%d, %v), want (0, io.EOF)", n, err)
+	}
+
+	// The B->A direction must still be usable after A's half-close.
+	const afterHalfClose = "still-alive"
+	if _, err := clientB.Write([]byte(afterHalfClose)); err != nil {
+		t.Fatalf("clientB.Write after half-close: %v", err)
+	}
+	gotAfter := make([]byte, len(afterHalfClose))
+	if _, err := io.ReadFull(clientA, gotAfter); err != nil {
+		t.Fatalf("clientA ReadFull after half-close: %v", err)
+	}
+	if !bytes.Equal(gotAfter, []byte(afterHalfClose)) {
+		t.Fatalf("B->A payload after half-close = %q, want %q", gotAfter, afterHalfClose)
+	}
+
+	clientB.CloseWrite()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("CopyRawConnBidir: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("CopyRawConnBidir did not return after both sides half-closed")
+	}
+
+	if countersA.Uplink.(*fakeCounter).Value() != int64(len(request)) {
+		t.Fatalf("countersA.Uplink = %d, want %d", countersA.Uplink.(*fakeCounter).Value(), len(request))
+	}
+	if want := int64(len(response) + len(afterHalfClose)); countersB.Downlink.(*fakeCounter).Value() != want {
+		t.Fatalf("countersB.Downlink = %d, want %d", countersB.Downlink.(*fakeCounter).Value(), want)
+	}
+}
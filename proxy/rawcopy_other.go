@@ -0,0 +1,6 @@
+//go:build !linux && !android && !windows
+
+package proxy
+
+// No zero-copy backend is registered on this platform; rawCopy stays nil and
+// CopyRawConnIfExist always falls back to buf.Copy.
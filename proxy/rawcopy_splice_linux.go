@@ -0,0 +1,28 @@
+//go:build linux || android
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"runtime"
+
+	"github.com/xtls/xray-core/common/errors"
+	"github.com/xtls/xray-core/common/net"
+	"github.com/xtls/xray-core/common/session"
+	"github.com/xtls/xray-core/common/signal"
+	"github.com/xtls/xray-core/features/stats"
+)
+
+// spliceCopy drives the kernel splice(2) fast path through *net.TCPConn.ReadFrom. It is the
+// only backend registered on Linux/Android, see rawcopy_linux.go.
+func spliceCopy(ctx context.Context, readerConn, writerConn *net.TCPConn, timer signal.ActivityUpdater, readCounter, writeCounter, userCounter stats.Counter) (bool, error) {
+	newError("CopyRawConn splice").WriteToLog(session.ExportIDToError(ctx))
+	runtime.Gosched() // necessary
+	w, err := writerConn.ReadFrom(readerConn)
+	addToCounters(w, readCounter, writeCounter, userCounter)
+	if err != nil && errors.Cause(err) != io.EOF {
+		return true, err
+	}
+	return true, nil
+}
@@ -0,0 +1,10 @@
+//go:build windows
+
+package proxy
+
+// Winsock has no documented TransmitFile (or equivalent) mode for socket-to-socket
+// transfer: TransmitFile's hFile argument must be an open file, not a peer socket handle,
+// so a prior attempt at this backend failed on every call and silently fell back to
+// buf.Copy. Rather than ship a backend that never actually engages, leave rawCopy nil on
+// Windows until there's a real zero-copy mechanism for this (e.g. AcceptEx/Registered I/O)
+// to build on; CopyRawConnIfExist already falls back to buf.Copy when rawCopy is nil.
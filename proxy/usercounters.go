@@ -0,0 +1,95 @@
+package proxy
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/xtls/xray-core/features/stats"
+)
+
+// counterRegistrar is the subset of stats.Manager that UserCounterManager needs. Depending
+// on this narrower interface, rather than the full stats.Manager, keeps UserCounterManager
+// testable without a real stats.Manager; any stats.Manager already satisfies it.
+type counterRegistrar interface {
+	RegisterCounter(string) (stats.Counter, error)
+	UnregisterCounter(string) error
+}
+
+// UserCounterManager is the concrete UserQuerier building block inbound and outbound
+// proxies hold onto so that, once a connection has picked its user, they can populate
+// session.Content with the matching UserCounters and later let operators enumerate users
+// and pull their live traffic. Counters are registered with the given stats.Manager under
+// `user>>>email>>>traffic>>>{up,down}link`, mirroring the existing
+// `outbound>>>tag>>>traffic>>>uplink` naming convention.
+type UserCounterManager struct {
+	sync.RWMutex
+	statsManager counterRegistrar
+	counters     map[string]*UserCounters
+}
+
+// NewUserCounterManager creates a UserCounterManager backed by statsManager. Proxies should
+// pass the same stats.Manager they already use for their per-tag counters.
+func NewUserCounterManager(statsManager counterRegistrar) *UserCounterManager {
+	return &UserCounterManager{
+		statsManager: statsManager,
+		counters:     make(map[string]*UserCounters),
+	}
+}
+
+// AddUser registers email, allocating (or reusing, if already present) its uplink/downlink
+// counters. A proxy's UserManager.AddUser should call this when a new user is added, then
+// use the returned UserCounters to populate session.Content for connections authenticated
+// to that user.
+func (m *UserCounterManager) AddUser(email string) (*UserCounters, error) {
+	m.Lock()
+	defer m.Unlock()
+	if c, found := m.counters[email]; found {
+		return c, nil
+	}
+	uplink, err := m.statsManager.RegisterCounter(fmt.Sprintf("user>>>%s>>>traffic>>>uplink", email))
+	if err != nil {
+		return nil, newError("failed to register uplink counter for ", email).Base(err)
+	}
+	downlink, err := m.statsManager.RegisterCounter(fmt.Sprintf("user>>>%s>>>traffic>>>downlink", email))
+	if err != nil {
+		m.statsManager.UnregisterCounter(fmt.Sprintf("user>>>%s>>>traffic>>>uplink", email))
+		return nil, newError("failed to register downlink counter for ", email).Base(err)
+	}
+	counters := &UserCounters{Uplink: uplink, Downlink: downlink}
+	m.counters[email] = counters
+	return counters, nil
+}
+
+// RemoveUser unregisters email's counters along with the manager's own entry for it. A
+// proxy's UserManager.RemoveUser should call this when a user is removed.
+func (m *UserCounterManager) RemoveUser(email string) {
+	m.Lock()
+	defer m.Unlock()
+	if _, found := m.counters[email]; !found {
+		return
+	}
+	delete(m.counters, email)
+	m.statsManager.UnregisterCounter(fmt.Sprintf("user>>>%s>>>traffic>>>uplink", email))
+	m.statsManager.UnregisterCounter(fmt.Sprintf("user>>>%s>>>traffic>>>downlink", email))
+}
+
+// ListUsers implements UserQuerier.
+func (m *UserCounterManager) ListUsers() []string {
+	m.RLock()
+	defer m.RUnlock()
+	emails := make([]string, 0, len(m.counters))
+	for email := range m.counters {
+		emails = append(emails, email)
+	}
+	return emails
+}
+
+// GetUserStats implements UserQuerier.
+func (m *UserCounterManager) GetUserStats(email string) (*UserCounters, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	c, found := m.counters[email]
+	return c, found
+}
+
+var _ UserQuerier = (*UserCounterManager)(nil)
@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/xtls/xray-core/features/stats"
+)
+
+type fakeCounter struct {
+	value int64
+}
+
+func (c *fakeCounter) Value() int64 {
+	return c.value
+}
+
+func (c *fakeCounter) Set(v int64) int64 {
+	prev := c.value
+	c.value = v
+	return prev
+}
+
+func (c *fakeCounter) Add(v int64) int64 {
+	c.value += v
+	return c.value
+}
+
+type fakeRegistrar struct {
+	registered   map[string]*fakeCounter
+	unregistered []string
+}
+
+func newFakeRegistrar() *fakeRegistrar {
+	return &fakeRegistrar{registered: make(map[string]*fakeCounter)}
+}
+
+func (r *fakeRegistrar) RegisterCounter(name string) (stats.Counter, error) {
+	c := &fakeCounter{}
+	r.registered[name] = c
+	return c, nil
+}
+
+func (r *fakeRegistrar) UnregisterCounter(name string) error {
+	delete(r.registered, name)
+	r.unregistered = append(r.unregistered, name)
+	return nil
+}
+
+func TestUserCounterManagerAddUserIdempotent(t *testing.T) {
+	registrar := newFakeRegistrar()
+	m := NewUserCounterManager(registrar)
+
+	first, err := m.AddUser("user@example.com")
+	if err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+	second, err := m.AddUser("user@example.com")
+	if err != nil {
+		t.Fatalf("AddUser (second call): %v", err)
+	}
+	if first != second {
+		t.Fatalf("AddUser returned different counters for the same email: %p != %p", first, second)
+	}
+	if len(registrar.registered) != 2 {
+		t.Fatalf("expected 2 counters registered, got %d", len(registrar.registered))
+	}
+}
+
+func TestUserCounterManagerRemoveUser(t *testing.T) {
+	registrar := newFakeRegistrar()
+	m := NewUserCounterManager(registrar)
+
+	if _, err := m.AddUser("user@example.com"); err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+	m.RemoveUser("user@example.com")
+
+	if _, found := m.GetUserStats("user@example.com"); found {
+		t.Fatalf("GetUserStats still reports user after RemoveUser")
+	}
+	wantUnregistered := []string{
+		"user>>>user@example.com>>>traffic>>>uplink",
+		"user>>>user@example.com>>>traffic>>>downlink",
+	}
+	sort.Strings(registrar.unregistered)
+	sort.Strings(wantUnregistered)
+	if len(registrar.unregistered) != len(wantUnregistered) {
+		t.Fatalf("expected %v unregistered, got %v", wantUnregistered, registrar.unregistered)
+	}
+	for i, name := range wantUnregistered {
+		if registrar.unregistered[i] != name {
+			t.Fatalf("expected %v unregistered, got %v", wantUnregistered, registrar.unregistered)
+		}
+	}
+}
+
+func TestUserCounterManagerListAndGetUserStats(t *testing.T) {
+	registrar := newFakeRegistrar()
+	m := NewUserCounterManager(registrar)
+
+	emails := []string{"a@example.com", "b@example.com"}
+	for _, email := range emails {
+		if _, err := m.AddUser(email); err != nil {
+			t.Fatalf("AddUser(%s): %v", email, err)
+		}
+	}
+
+	got := m.ListUsers()
+	sort.Strings(got)
+	if len(got) != len(emails) {
+		t.Fatalf("expected %v, got %v", emails, got)
+	}
+	for i, email := range emails {
+		if got[i] != email {
+			t.Fatalf("expected %v, got %v", emails, got)
+		}
+		if counters, found := m.GetUserStats(email); !found || counters == nil {
+			t.Fatalf("GetUserStats(%s) = %v, %v; want found", email, counters, found)
+		}
+	}
+
+	if _, found := m.GetUserStats("missing@example.com"); found {
+		t.Fatalf("GetUserStats reported found for an email that was never added")
+	}
+}